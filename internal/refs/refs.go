@@ -0,0 +1,76 @@
+// Package refs normalizes and validates the image repository names and tags
+// derived from upstream distro metadata, so a malformed upstream name (e.g.
+// "Ubuntu 24.04 LTS") fails loudly here rather than mid-push.
+package refs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/distribution/reference"
+)
+
+var (
+	disallowedRepoChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+	repeatedSeparators  = regexp.MustCompile(`[._-]{2,}`)
+	disallowedTagChars  = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+const maxTagLength = 128
+
+// NormalizeRepository turns an arbitrary distro name into a valid Docker/OCI
+// repository path component: lowercased, with any character outside
+// [a-z0-9._-] replaced by "-", and runs of separators collapsed.
+func NormalizeRepository(name string) string {
+	normalized := strings.ToLower(name)
+	normalized = disallowedRepoChars.ReplaceAllString(normalized, "-")
+	normalized = repeatedSeparators.ReplaceAllString(normalized, "-")
+	return strings.Trim(normalized, "-._")
+}
+
+// SanitizeTag turns an arbitrary version string into a valid tag matching
+// reference's grammar ([A-Za-z0-9_][A-Za-z0-9._-]{0,127}): disallowed
+// characters are replaced, a leading "." or "-" is stripped, and the result
+// is truncated to the maximum tag length.
+func SanitizeTag(tag string) string {
+	sanitized := disallowedTagChars.ReplaceAllString(tag, "-")
+	sanitized = strings.TrimLeft(sanitized, ".-")
+	if sanitized == "" {
+		sanitized = "unknown"
+	}
+	if len(sanitized) > maxTagLength {
+		sanitized = sanitized[:maxTagLength]
+	}
+	return sanitized
+}
+
+// NewTagged normalizes repository and sanitizes tag, builds a
+// reference.NamedTagged from them, and confirms the result round-trips
+// through reference.Parse. Callers should treat a non-nil error as fatal at
+// the point the reference is built, rather than letting a bad name or tag
+// reach pushDockerImage and fail mid-push.
+func NewTagged(repository string, tag string) (reference.NamedTagged, error) {
+	normalizedRepo := NormalizeRepository(repository)
+	sanitizedTag := SanitizeTag(tag)
+
+	named, err := reference.WithName(normalizedRepo)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository %q (from %q): %w", normalizedRepo, repository, err)
+	}
+
+	tagged, err := reference.WithTag(named, sanitizedTag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag %q (from %q): %w", sanitizedTag, tag, err)
+	}
+
+	// reference.ParseNamed requires a canonical (domain-qualified) name and
+	// would reject the short, registry-less repo names used here (they're
+	// joined with a registry host later, in registryDestinations). Parse
+	// validates the same grammar without that requirement.
+	if _, err := reference.Parse(tagged.String()); err != nil {
+		return nil, fmt.Errorf("built reference %q does not round-trip: %w", tagged.String(), err)
+	}
+
+	return tagged, nil
+}
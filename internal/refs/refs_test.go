@@ -0,0 +1,83 @@
+package refs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/distribution/reference"
+)
+
+func TestNormalizeRepository(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"already valid", "debian", "debian"},
+		{"uppercase", "Ubuntu", "ubuntu"},
+		{"spaces and dots", "Ubuntu 24.04 LTS", "ubuntu-24.04-lts"},
+		{"plus sign", "gcc+", "gcc"},
+		{"repeated separators collapse", "oracle--linux", "oracle-linux"},
+		{"leading and trailing separators trimmed", "-suse-", "suse"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := NormalizeRepository(c.in)
+			if got != c.want {
+				t.Errorf("NormalizeRepository(%q) = %q, want %q", c.in, got, c.want)
+			}
+			if _, err := reference.WithName(got); err != nil {
+				t.Errorf("NormalizeRepository(%q) = %q does not round-trip through reference.WithName: %v", c.in, got, err)
+			}
+		})
+	}
+}
+
+func TestSanitizeTag(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"already valid", "24.04", "24.04"},
+		{"plus sign", "1.0+bionic", "1.0-bionic"},
+		{"leading dot stripped", ".24.04", "24.04"},
+		{"leading dash stripped", "-24.04", "24.04"},
+		{"leading digit kept", "24.04", "24.04"},
+		{"empty falls back to unknown", "", "unknown"},
+		{"truncated to max length", strings.Repeat("a", maxTagLength+10), strings.Repeat("a", maxTagLength)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := SanitizeTag(c.in)
+			if got != c.want {
+				t.Errorf("SanitizeTag(%q) = %q, want %q", c.in, got, c.want)
+			}
+			if len(got) > maxTagLength {
+				t.Errorf("SanitizeTag(%q) = %q exceeds maxTagLength %d", c.in, got, maxTagLength)
+			}
+		})
+	}
+}
+
+func TestNewTagged(t *testing.T) {
+	tagged, err := NewTagged("Ubuntu 24.04 LTS", "24.04.2+lts")
+	if err != nil {
+		t.Fatalf("NewTagged returned error: %v", err)
+	}
+	if got, want := tagged.Name(), "ubuntu-24.04-lts"; got != want {
+		t.Errorf("tagged.Name() = %q, want %q", got, want)
+	}
+	if got, want := tagged.Tag(), "24.04.2-lts"; got != want {
+		t.Errorf("tagged.Tag() = %q, want %q", got, want)
+	}
+	if _, err := reference.Parse(tagged.String()); err != nil {
+		t.Errorf("tagged.String() = %q does not round-trip through reference.Parse: %v", tagged.String(), err)
+	}
+}
+
+func TestNewTaggedRejectsEmptyRepository(t *testing.T) {
+	if _, err := NewTagged("+++", "24.04"); err == nil {
+		t.Fatal("NewTagged with an all-disallowed-character repository name returned no error")
+	}
+}
@@ -2,7 +2,11 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -12,8 +16,22 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/wsl-images/images/internal/refs"
 )
 
 // UrlInfo represents the URL and its checksum.
@@ -38,44 +56,183 @@ type ModernDists struct {
 	// The "Distributions" key is ignored for this workflow.
 }
 
+var (
+	outputDir = flag.String("output-dir", "", "if set, write each built image as an OCI image layout under <output-dir>/<distro>, in addition to (or instead of, with -skip-registry-push) pushing to registries")
+
+	skipRegistryPush = flag.Bool("skip-registry-push", false, "skip pushing to GHCR and Quay.io; useful with -output-dir for air-gapped or registry-less runs")
+
+	concurrency = flag.Int("j", runtime.NumCPU(), "maximum number of distributions to build and push concurrently")
+
+	dryRun = flag.Bool("dry-run", false, "walk the distribution list and log what would happen, without downloading, building, or pushing anything")
+)
+
 func main() {
+	flag.Parse()
+
 	// Fetch distribution info from GitHub
 	dists := fetchDistributionInfo()
 
-	// Process all modern distribution groups
+	// Flatten the group/distro nesting into a single work list so the whole
+	// batch can be fanned out across a bounded pool of goroutines, rather than
+	// processing one distro at a time.
+	type distroJob struct {
+		groupName string
+		distro    DistroDetail
+	}
+	var jobs []distroJob
 	for groupName, distroList := range dists.ModernDistributions {
-		log.Printf("Processing distribution group: %s", groupName)
-
-		// Process each distribution in the group
 		for _, distro := range distroList {
-			log.Printf("Building image for: %s (%s)", distro.Name, distro.FriendlyName)
+			jobs = append(jobs, distroJob{groupName, distro})
+		}
+	}
+
+	// errgroup.WithContext cancels ctx as soon as any job returns an error, so
+	// in-flight downloads and pushes for other distros abort instead of
+	// running to completion after the batch is already doomed to fail. The
+	// in-process build step does not watch ctx; a job that is building stops
+	// at its next download or push.
+	g, ctx := errgroup.WithContext(context.Background())
+	g.SetLimit(*concurrency)
+
+	for _, job := range jobs {
+		job := job
+		g.Go(func() error {
+			return processDistro(ctx, job.groupName, job.distro)
+		})
+	}
 
-			// Create a temporary file for the distribution tarball
-			tarFilePath := fmt.Sprintf("%s.tar", distro.Name)
+	if err := g.Wait(); err != nil {
+		log.Fatalf("Failed to process one or more distributions: %v", err)
+	}
 
-			// Download the distribution tarball (AMD64 only)
-			downloadDistributionTarball(distro.Amd64Url.Url, tarFilePath)
+	log.Printf("All distributions have been processed successfully")
+}
 
-			// Extract the version tag from the tarball
-			tag := extractTagFromTarball(tarFilePath, distro.Amd64Url.Url)
+// processDistro runs the full download/build/push pipeline for a single
+// distribution. It's safe to run concurrently for different distros: each
+// call logs through its own prefixing *log.Logger so interleaved output from
+// the worker pool stays attributable, and works out of its own per-job temp
+// directory so concurrent jobs never share a tarball path. ctx is checked
+// before network calls so the job aborts promptly once the errgroup cancels
+// it, rather than finishing work that's already moot.
+func processDistro(ctx context.Context, groupName string, distro DistroDetail) error {
+	logger := log.New(log.Writer(), fmt.Sprintf("[%s/%s] ", groupName, distro.Name), log.LstdFlags)
+	logger.Printf("Building image for: %s (%s)", distro.Name, distro.FriendlyName)
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-			// With this line (using underscore to ignore unused return value):
-			baseImageName, _, dateTag := importTarballToDocker(tarFilePath, tag, distro)
+	jobDir, err := os.MkdirTemp("", fmt.Sprintf("wsl-images-%s-*", refs.NormalizeRepository(distro.Name)))
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for %s: %w", distro.Name, err)
+	}
+	defer os.RemoveAll(jobDir)
 
-			// Push the Docker image to GitHub Packages
-			pushDockerImage(baseImageName, tag, dateTag)
+	// Always build the AMD64 architecture; ARM64 is built alongside it
+	// when the distro publishes a tarball for it.
+	archURLs := map[string]UrlInfo{"amd64": distro.Amd64Url}
+	if distro.Arm64Url != nil {
+		archURLs["arm64"] = *distro.Arm64Url
+	}
 
-			// Clean up the tarball
-			err := os.Remove(tarFilePath)
-			if err != nil {
-				log.Printf("Warning: Failed to clean up tarball: %v", err)
-			}
+	baseImageName := refs.NormalizeRepository(distro.Name)
+	dateTag := time.Now().Format("2006-01-02-150405")
 
-			log.Printf("Completed building image for: %s", distro.Name)
+	if *dryRun {
+		archs := make([]string, 0, len(archURLs))
+		for arch := range archURLs {
+			archs = append(archs, arch)
 		}
+		logger.Printf("[dry-run] would download %v, build images for %v, and publish as %s", archs, archs, baseImageName)
+		return nil
 	}
 
-	log.Printf("All distributions have been processed successfully")
+	if !*skipRegistryPush {
+		// Check the amd64-specific "latest-amd64" alias rather than the plain
+		// "latest" tag: the plain tag is a manifest list (or, for single-arch
+		// distros, an alias of it) assembled from archImages further down,
+		// which doesn't exist yet on a first run, whereas "latest-amd64" is
+		// pushed unconditionally for every distro regardless of how many
+		// architectures it publishes.
+		ghcrBase, quayRepo := registryDestinations(baseImageName)
+		repoName := strings.ToLower(baseImageName)
+		ghcrCheckRef := fmt.Sprintf("%s:latest-amd64", ghcrBase)
+		quayCheckRef := fmt.Sprintf("%s:%s-latest-amd64", quayRepo, repoName)
+		if alreadyPublished(ctx, ghcrCheckRef, distro.Amd64Url.Sha256) &&
+			alreadyPublished(ctx, quayCheckRef, distro.Amd64Url.Sha256) {
+			logger.Printf("%s is already up to date (source sha256:%s), skipping", distro.Name, distro.Amd64Url.Sha256)
+			return nil
+		}
+	}
+
+	var tag string
+	archImages := make(map[string]v1.Image, len(archURLs))
+	archDigests := make(map[string]string, len(archURLs))
+
+	for arch, urlInfo := range archURLs {
+		// Tarball path is scoped to this job's own temp directory, so
+		// concurrent jobs can never collide on it even if distro.Name isn't
+		// unique across groups in the upstream JSON.
+		tarFilePath := filepath.Join(jobDir, fmt.Sprintf("%s-%s.tar", distro.Name, arch))
+
+		// Download the distribution tarball for this architecture,
+		// verifying it against the published Sha256 as we go.
+		sourceDigest, err := downloadDistributionTarball(ctx, logger, urlInfo.Url, tarFilePath, urlInfo.Sha256)
+		if err != nil {
+			return fmt.Errorf("%s (%s): %w", distro.Name, arch, err)
+		}
+		archDigests[arch] = sourceDigest
+
+		// Extract the version tag from the tarball (identical across archs)
+		archTag, err := extractTagFromTarball(logger, tarFilePath, urlInfo.Url)
+		if err != nil {
+			return fmt.Errorf("%s (%s): %w", distro.Name, arch, err)
+		}
+		if tag == "" {
+			tag = archTag
+		}
+
+		img, err := buildArchImage(tarFilePath, distro, arch, sourceDigest)
+		if err != nil {
+			return fmt.Errorf("failed to build image for %s (%s): %w", distro.Name, arch, err)
+		}
+		archImages[arch] = img
+
+		// Clean up the tarball
+		if err := os.Remove(tarFilePath); err != nil {
+			logger.Printf("Warning: Failed to clean up tarball: %v", err)
+		}
+	}
+
+	// Sanitize and validate the reference before any push is attempted,
+	// so a malformed upstream name or version fails loudly here rather
+	// than mid-push.
+	tag = refs.SanitizeTag(tag)
+	if _, err := refs.NewTagged(baseImageName, tag); err != nil {
+		return fmt.Errorf("invalid image reference for %s: %w", distro.Name, err)
+	}
+
+	// Push every architecture's image, then assemble a manifest list so
+	// `docker pull` resolves the right architecture automatically.
+	if !*skipRegistryPush {
+		if err := pushDockerImage(ctx, logger, baseImageName, tag, dateTag, archImages, archDigests); err != nil {
+			return fmt.Errorf("failed to push images for %s: %w", distro.Name, err)
+		}
+	}
+
+	// Additionally (or instead) write an OCI image layout to disk, for
+	// air-gapped mirroring, `cosign sign --key ... oci-dir:...`, or
+	// feeding registries that don't speak the Docker Registry v2 push
+	// protocol.
+	if *outputDir != "" {
+		if err := writeOCILayout(logger, *outputDir, baseImageName, archImages, archDigests); err != nil {
+			return fmt.Errorf("failed to write OCI image layout for %s: %w", distro.Name, err)
+		}
+	}
+
+	logger.Printf("Completed building image for: %s", distro.Name)
+	return nil
 }
 
 // fetchDistributionInfo fetches and parses the distribution information JSON
@@ -99,33 +256,50 @@ func fetchDistributionInfo() ModernDists {
 	return dists
 }
 
-// downloadDistributionTarball downloads the tarball from the given URL
-func downloadDistributionTarball(url string, filePath string) {
-	log.Printf("Tarball URL: %s", url)
-	if err := downloadFile(filePath, url); err != nil {
-		log.Fatalf("Failed to download tarball: %v", err)
+// downloadDistributionTarball downloads the tarball from the given URL and
+// verifies it against expectedSha256 (the Sha256 field published alongside it
+// in DistributionInfo.json), returning an error rather than importing a
+// tarball that doesn't match - guarding against a corrupted or tampered CDN
+// response. It returns the hex-encoded digest of the downloaded tarball. When
+// expectedSha256 is empty, the check is skipped with a warning and that
+// digest is returned unverified.
+func downloadDistributionTarball(ctx context.Context, logger *log.Logger, url string, filePath string, expectedSha256 string) (string, error) {
+	logger.Printf("Tarball URL: %s", url)
+	digest, err := downloadFile(ctx, filePath, url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download tarball: %w", err)
+	}
+	logger.Printf("Downloaded tarball to %s (sha256:%s)", filePath, digest)
+
+	if expectedSha256 == "" {
+		logger.Printf("Warning: no published Sha256 for %s, skipping integrity check", url)
+		return digest, nil
 	}
-	log.Printf("Downloaded tarball to %s", filePath)
+	if !strings.EqualFold(digest, expectedSha256) {
+		return "", fmt.Errorf("tarball integrity check failed for %s: expected sha256:%s, got sha256:%s", url, expectedSha256, digest)
+	}
+	logger.Printf("Verified tarball integrity: sha256:%s", digest)
+	return digest, nil
 }
 
 // extractTagFromTarball extracts the version tag from the tarball
-func extractTagFromTarball(tarFilePath string, url string) string {
+func extractTagFromTarball(logger *log.Logger, tarFilePath string, url string) (string, error) {
 	// First try direct tar extraction
 	tag, err := extractTagFromTar(tarFilePath)
 	if err != nil {
-		log.Printf("Could not extract tag from tarball: %v", err)
+		logger.Printf("Could not extract tag from tarball: %v", err)
 
 		// Fall back to extracting version from URL
-		log.Printf("Attempting to extract version from URL: %s", url)
+		logger.Printf("Attempting to extract version from URL: %s", url)
 		tag = extractVersionFromURL(url)
 		if tag != "" {
-			log.Printf("Extracted tag from URL: %s", tag)
-			return tag
+			logger.Printf("Extracted tag from URL: %s", tag)
+			return tag, nil
 		}
-		log.Fatalf("Failed to extract version information")
+		return "", fmt.Errorf("failed to extract version information")
 	}
-	log.Printf("Extracted tag from os-release: %s", tag)
-	return tag
+	logger.Printf("Extracted tag from os-release: %s", tag)
+	return tag, nil
 }
 
 // extractVersionFromURL extracts version information from the URL or filename
@@ -142,163 +316,303 @@ func extractVersionFromURL(url string) string {
 	return ""
 }
 
-// importTarballToDocker imports the tarball into Docker
-func importTarballToDocker(tarFilePath string, tag string, distro DistroDetail) (string, string, string) {
-	// Base image name without tag
-	baseImageName := strings.ToLower(distro.Name)
+// sourceDigestLabel is the OCI config label (and mirrored manifest list
+// annotation) recording the sha256 of the upstream Microsoft tarball an image
+// was built from. Reading it back from the registry lets us recognize an
+// unchanged upstream and skip rebuilding it.
+const sourceDigestLabel = "org.wsl-images.source.sha256"
+
+// buildArchImage builds an OCI image for a single architecture directly from
+// the downloaded rootfs tarball: the tarball becomes the image's only layer,
+// appended onto empty.Image, with a minimal config describing the distro.
+// sourceDigest (the verified sha256 of the source tarball) is stamped onto
+// the config as sourceDigestLabel for provenance and change detection. This
+// happens entirely in-process, with no local Docker daemon involved, so it's
+// safe to call concurrently for different distros/architectures.
+func buildArchImage(tarFilePath string, distro DistroDetail, arch string, sourceDigest string) (v1.Image, error) {
+	tarFile, err := os.Open(tarFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open tarball: %w", err)
+	}
+	defer tarFile.Close()
 
-	// Image name with version tag
-	imageNameWithTag := baseImageName + ":" + tag
+	layer, err := tarball.LayerFromReader(tarFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build rootfs layer: %w", err)
+	}
 
-	// Import the image with the version tag
-	importCmd := exec.Command("docker", "import", tarFilePath, imageNameWithTag)
-	importCmd.Stdout = os.Stdout
-	importCmd.Stderr = os.Stderr
-	if err := importCmd.Run(); err != nil {
-		log.Fatalf("Failed to import docker image: %v", err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append rootfs layer: %w", err)
 	}
-	log.Printf("Docker image imported with tag %s", imageNameWithTag)
 
-	// Tag the image as latest
-	latestImageName := baseImageName + ":latest"
-	tagLatestCmd := exec.Command("docker", "tag", imageNameWithTag, latestImageName)
-	if err := tagLatestCmd.Run(); err != nil {
-		log.Printf("Warning: Failed to tag image as latest: %v", err)
-	} else {
-		log.Printf("Image tagged as %s", latestImageName)
+	cfgFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image config: %w", err)
+	}
+	cfgFile = cfgFile.DeepCopy()
+	cfgFile.OS = "linux"
+	cfgFile.Architecture = arch
+	cfgFile.Config.Cmd = []string{"/bin/bash"}
+	cfgFile.Config.Env = []string{"PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"}
+	cfgFile.Config.Labels = map[string]string{
+		"org.opencontainers.image.title": distro.FriendlyName,
+		sourceDigestLabel:                sourceDigest,
 	}
 
-	// Tag with today's date and time
-	currentTime := time.Now().Format("2006-01-02-150405")
-	dateImageName := baseImageName + ":" + currentTime
-	tagDateCmd := exec.Command("docker", "tag", imageNameWithTag, dateImageName)
-	if err := tagDateCmd.Run(); err != nil {
-		log.Printf("Warning: Failed to tag image with date: %v", err)
-	} else {
-		log.Printf("Image tagged as %s", dateImageName)
+	return mutate.ConfigFile(img, cfgFile)
+}
+
+// pushDockerImage writes every architecture's image directly to GitHub
+// Packages and Quay.io via go-containerregistry, with no Docker daemon
+// involved, then assembles a manifest list (fat manifest) under the plain
+// version/latest/date tags so that `docker pull` resolves the right
+// architecture on either amd64 or arm64 WSL hosts. archDigests carries each
+// architecture's verified source tarball digest, surfaced here for
+// provenance logging alongside the image's own (deterministic) content
+// digest. Pushes for distinct registries and tags are independent of each
+// other and safe to run concurrently with other distros' pushes.
+func pushDockerImage(ctx context.Context, logger *log.Logger, baseImageName string, tag string, dateTag string, archImages map[string]v1.Image, archDigests map[string]string) error {
+	repoName := strings.ToLower(baseImageName)
+	ghcrBase, quayRepo := registryDestinations(baseImageName)
+
+	// Write each architecture's image straight to GitHub Packages, plus a
+	// "latest-<arch>" alias that doesn't depend on the extracted version tag,
+	// so alreadyPublished has a ref it can check before that tag is known.
+	var digestRefs []string
+	for arch, img := range archImages {
+		ghcrArchTag := fmt.Sprintf("%s:%s-%s", ghcrBase, tag, arch)
+		if err := writeImage(ctx, logger, ghcrArchTag, img); err != nil {
+			return err
+		}
+		if digestRef := logProvenance(logger, ghcrArchTag, img, archDigests[arch]); digestRef != "" {
+			digestRefs = append(digestRefs, digestRef)
+		}
+
+		if err := writeImage(ctx, logger, fmt.Sprintf("%s:latest-%s", ghcrBase, arch), img); err != nil {
+			return err
+		}
 	}
+	for _, versionedTag := range []string{tag, "latest", dateTag} {
+		if err := pushManifestList(ctx, logger, fmt.Sprintf("%s:%s", ghcrBase, versionedTag), archImages, archDigests); err != nil {
+			return err
+		}
+	}
+	logger.Printf("Images for %s pushed successfully to GitHub Packages, addressable by digest at: %s", baseImageName, strings.Join(digestRefs, ", "))
+
+	// Write each architecture's image to Quay.io, under its shared images
+	// repo, distinguishing distributions via the tag rather than the repo name.
+	digestRefs = nil
+	for arch, img := range archImages {
+		quayArchTag := fmt.Sprintf("%s:%s-%s-%s", quayRepo, repoName, tag, arch)
+		if err := writeImage(ctx, logger, quayArchTag, img); err != nil {
+			return err
+		}
+		if digestRef := logProvenance(logger, quayArchTag, img, archDigests[arch]); digestRef != "" {
+			digestRefs = append(digestRefs, digestRef)
+		}
 
-	return baseImageName, imageNameWithTag, currentTime
+		if err := writeImage(ctx, logger, fmt.Sprintf("%s:%s-latest-%s", quayRepo, repoName, arch), img); err != nil {
+			return err
+		}
+	}
+	for _, versionedTag := range []string{tag, "latest", dateTag} {
+		if err := pushManifestList(ctx, logger, fmt.Sprintf("%s:%s-%s", quayRepo, repoName, versionedTag), archImages, archDigests); err != nil {
+			return err
+		}
+	}
+	logger.Printf("Images for %s pushed successfully to Quay.io, addressable by digest at: %s", baseImageName, strings.Join(digestRefs, ", "))
+	return nil
 }
 
-func pushDockerImage(baseImageName string, tag string, dateTag string) {
+// registryDestinations returns the GHCR and Quay.io repository paths (without
+// a tag) that images for baseImageName are published to.
+func registryDestinations(baseImageName string) (ghcrBase string, quayRepo string) {
 	// Get GitHub username from environment (set by GitHub Actions)
 	githubUsername := os.Getenv("GITHUB_REPOSITORY_OWNER")
 	if githubUsername == "" {
 		// Fallback to local user if not in GitHub Actions
 		githubUsername = "wsl-images"
 	}
-
 	// Make sure username is lowercase for GitHub Container Registry
 	githubUsername = strings.ToLower(githubUsername)
 
-	// Format for GitHub container registry
 	repoName := strings.ToLower(baseImageName)
-	ghcrBase := fmt.Sprintf("ghcr.io/%s/%s", githubUsername, repoName)
-
-	// Format for Quay.io repository - without tag
-	quayRepo := "quay.io/wsl-images/images"
-
-	// Tag images for the GitHub container registry
-	imageNameWithTag := baseImageName + ":" + tag
-	ghcrImageTag := ghcrBase + ":" + tag
-	ghcrLatestTag := ghcrBase + ":latest"
-	ghcrDateTag := ghcrBase + ":" + dateTag
-
-	// Tag with GitHub container registry URL
-	log.Printf("Tagging %s as %s", imageNameWithTag, ghcrImageTag)
-	tagCmd := exec.Command("docker", "tag", imageNameWithTag, ghcrImageTag)
-	tagCmd.Stderr = os.Stderr
-	if err := tagCmd.Run(); err != nil {
-		log.Fatalf("Failed to tag image for GitHub Packages: %v", err)
-	}
-
-	// Tag latest for GitHub
-	log.Printf("Tagging %s as %s", imageNameWithTag, ghcrLatestTag)
-	tagLatestCmd := exec.Command("docker", "tag", imageNameWithTag, ghcrLatestTag)
-	tagLatestCmd.Stderr = os.Stderr
-	if err := tagLatestCmd.Run(); err != nil {
-		log.Fatalf("Failed to tag latest image for GitHub Packages: %v", err)
-	}
-
-	// Tag with date for GitHub
-	log.Printf("Tagging %s as %s", imageNameWithTag, ghcrDateTag)
-	tagDateCmd := exec.Command("docker", "tag", imageNameWithTag, ghcrDateTag)
-	tagDateCmd.Stderr = os.Stderr
-	if err := tagDateCmd.Run(); err != nil {
-		log.Fatalf("Failed to tag dated image for GitHub Packages: %v", err)
-	}
-
-	// Push all GitHub tags
-	log.Printf("Pushing image %s to GitHub Packages", ghcrBase)
-	pushCmd := exec.Command("docker", "push", "--all-tags", ghcrBase)
-	pushCmd.Stdout = os.Stdout
-	pushCmd.Stderr = os.Stderr
-	if err := pushCmd.Run(); err != nil {
-		log.Fatalf("Failed to push docker images to GitHub: %v", err)
-	}
-	log.Printf("Docker images pushed successfully to GitHub Packages")
-
-	// Tag images for Quay.io with proper tags that include the distribution name
-	quayImageTag := fmt.Sprintf("%s:%s-%s", quayRepo, repoName, tag)
-	quayLatestTag := fmt.Sprintf("%s:%s-latest", quayRepo, repoName)
-	quayDateTag := fmt.Sprintf("%s:%s-%s", quayRepo, repoName, dateTag)
-
-	// Tag with Quay.io repository URL
-	log.Printf("Tagging %s as %s", imageNameWithTag, quayImageTag)
-	tagQuayCmd := exec.Command("docker", "tag", imageNameWithTag, quayImageTag)
-	tagQuayCmd.Stderr = os.Stderr
-	if err := tagQuayCmd.Run(); err != nil {
-		log.Fatalf("Failed to tag image for Quay.io: %v", err)
-	}
-
-	// Tag latest for Quay.io
-	log.Printf("Tagging %s as %s", imageNameWithTag, quayLatestTag)
-	tagQuayLatestCmd := exec.Command("docker", "tag", imageNameWithTag, quayLatestTag)
-	tagQuayLatestCmd.Stderr = os.Stderr
-	if err := tagQuayLatestCmd.Run(); err != nil {
-		log.Fatalf("Failed to tag latest image for Quay.io: %v", err)
-	}
-
-	// Tag with date for Quay.io
-	log.Printf("Tagging %s as %s", imageNameWithTag, quayDateTag)
-	tagQuayDateCmd := exec.Command("docker", "tag", imageNameWithTag, quayDateTag)
-	tagQuayDateCmd.Stderr = os.Stderr
-	if err := tagQuayDateCmd.Run(); err != nil {
-		log.Fatalf("Failed to tag dated image for Quay.io: %v", err)
-	}
-
-	// Push each Quay.io tag individually since we can't use --all-tags
-	log.Printf("Pushing image tags to Quay.io")
-
-	for _, tag := range []string{quayImageTag, quayLatestTag, quayDateTag} {
-		pushQuayTagCmd := exec.Command("docker", "push", tag)
-		pushQuayTagCmd.Stdout = os.Stdout
-		pushQuayTagCmd.Stderr = os.Stderr
-		if err := pushQuayTagCmd.Run(); err != nil {
-			log.Fatalf("Failed to push docker image to Quay.io: %v", err)
+	return fmt.Sprintf("ghcr.io/%s/%s", githubUsername, repoName), "quay.io/wsl-images/images"
+}
+
+// alreadyPublished reports whether ref's image already carries sourceDigest
+// in its sourceDigestLabel config label, meaning the upstream tarball hasn't
+// changed since the last push and the build/push cycle can be skipped. Any
+// error reading the remote (including it not existing yet) is treated as
+// "not up to date", so the normal build proceeds.
+func alreadyPublished(ctx context.Context, ref string, sourceDigest string) bool {
+	tag, err := name.NewTag(ref)
+	if err != nil {
+		return false
+	}
+	img, err := remote.Image(tag, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx))
+	if err != nil {
+		return false
+	}
+	cfgFile, err := img.ConfigFile()
+	if err != nil {
+		return false
+	}
+	label, ok := cfgFile.Config.Labels[sourceDigestLabel]
+	return ok && label == sourceDigest
+}
+
+// writeImage pushes img directly to ref, with credentials resolved by
+// authn.DefaultKeychain (DOCKER_CONFIG, GHCR tokens, Quay robot accounts, ...).
+// ctx is attached to the push so it aborts if ctx is canceled.
+func writeImage(ctx context.Context, logger *log.Logger, ref string, img v1.Image) error {
+	tag, err := name.NewTag(ref)
+	if err != nil {
+		return fmt.Errorf("invalid image reference %s: %w", ref, err)
+	}
+	logger.Printf("Pushing %s", ref)
+	if err := remote.Write(tag, img, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to push %s: %w", ref, err)
+	}
+	return nil
+}
+
+// digestReference builds the immutable "repo@sha256:..." reference for img as
+// pushed under ref, so callers have a content-addressable reference that
+// keeps resolving to this exact image regardless of what the mutable tag ref
+// later points to.
+func digestReference(ref string, img v1.Image) (string, error) {
+	tag, err := name.NewTag(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %s: %w", ref, err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to compute digest for %s: %w", ref, err)
+	}
+	digestRef, err := name.NewDigest(fmt.Sprintf("%s@%s", tag.Context().Name(), digest))
+	if err != nil {
+		return "", fmt.Errorf("failed to build digest reference for %s: %w", ref, err)
+	}
+	return digestRef.String(), nil
+}
+
+// logProvenance builds and logs the pushed image's immutable digest
+// reference alongside the verified digest of the source tarball it was built
+// from, so each registry image can be traced back to the Microsoft-published
+// tarball, and returns the digest reference so callers can surface it
+// alongside the mutable tag. Returns "" if sourceDigest is unknown or the
+// digest reference can't be built.
+func logProvenance(logger *log.Logger, ref string, img v1.Image, sourceDigest string) string {
+	if sourceDigest == "" {
+		return ""
+	}
+	digestRef, err := digestReference(ref, img)
+	if err != nil {
+		logger.Printf("Warning: %v", err)
+		return ""
+	}
+	logger.Printf("%s -> %s (source sha256:%s)", ref, digestRef, sourceDigest)
+	return digestRef
+}
+
+// pushManifestList assembles the per-architecture images in archImages
+// (keyed by "amd64"/"arm64") into a single OCI manifest list and pushes it to
+// manifestRef, so that `docker pull` resolves the right architecture
+// automatically. With a single architecture, no manifest list is built: that
+// image is pushed directly under manifestRef instead.
+func pushManifestList(ctx context.Context, logger *log.Logger, manifestRef string, archImages map[string]v1.Image, archDigests map[string]string) error {
+	if len(archImages) < 2 {
+		// No fan-out needed, but manifestRef still needs to resolve to
+		// something: alias the single architecture's image directly under
+		// it, since it was only ever pushed under its arch-suffixed tag.
+		for arch, img := range archImages {
+			logger.Printf("Only one architecture (%s) available for %s, pushing it directly instead of a manifest list", arch, manifestRef)
+			return writeImage(ctx, logger, manifestRef, img)
 		}
+		return nil
+	}
+
+	ref, err := name.NewTag(manifestRef)
+	if err != nil {
+		return fmt.Errorf("invalid manifest reference %s: %w", manifestRef, err)
+	}
+
+	idx := buildManifestIndex(archImages, archDigests)
+
+	logger.Printf("Pushing manifest list %s", manifestRef)
+	if err := remote.WriteIndex(ref, idx, remote.WithAuthFromKeychain(authn.DefaultKeychain), remote.WithContext(ctx)); err != nil {
+		return fmt.Errorf("failed to push manifest list %s: %w", manifestRef, err)
+	}
+	return nil
+}
+
+// buildManifestIndex assembles the per-architecture images in archImages
+// (keyed by "amd64"/"arm64") into a single OCI image index, annotated with
+// the amd64 build's source tarball digest for provenance.
+func buildManifestIndex(archImages map[string]v1.Image, archDigests map[string]string) v1.ImageIndex {
+	var idx v1.ImageIndex = empty.Index
+	for arch, img := range archImages {
+		idx = mutate.AppendManifests(idx, mutate.IndexAddendum{
+			Add: img,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{OS: "linux", Architecture: arch},
+			},
+		})
+	}
+
+	// Mirror the amd64 source digest as an annotation on the index itself, so
+	// alreadyPublished's check has an equivalent at the index level.
+	if sourceDigest, ok := archDigests["amd64"]; ok && sourceDigest != "" {
+		idx = mutate.Annotations(idx, map[string]string{sourceDigestLabel: sourceDigest}).(v1.ImageIndex)
 	}
 
-	log.Printf("Docker images pushed successfully to Quay.io")
+	return idx
 }
 
-// downloadFile downloads a file from the given URL and saves it to the specified filepath
-func downloadFile(filepath string, url string) error {
+// writeOCILayout writes archImages as an OCI image layout (oci-layout,
+// index.json, blobs/sha256/...) under <outputDir>/<baseImageName>, as an
+// alternative to pushing to a registry - useful for air-gapped mirroring or
+// feeding tools that consume an OCI layout directly.
+func writeOCILayout(logger *log.Logger, outputDir string, baseImageName string, archImages map[string]v1.Image, archDigests map[string]string) error {
+	dir := filepath.Join(outputDir, baseImageName)
+	idx := buildManifestIndex(archImages, archDigests)
+
+	logger.Printf("Writing OCI image layout for %s to %s", baseImageName, dir)
+	if _, err := layout.Write(dir, idx); err != nil {
+		return fmt.Errorf("failed to write OCI image layout to %s: %w", dir, err)
+	}
+	return nil
+}
+
+// downloadFile downloads a file from the given URL, writes it to filepath,
+// and returns its hex-encoded sha256 digest computed as it streams to disk.
+// ctx is attached to the request so the download aborts if ctx is canceled
+// (e.g. another distro in the same batch failed) instead of running to
+// completion regardless.
+func downloadFile(ctx context.Context, filepath string, url string) (string, error) {
 	out, err := os.Create(filepath)
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer out.Close()
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return err
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	_, err = io.Copy(out, resp.Body)
-	return err
+	hasher := sha256.New()
+	if _, err := io.Copy(out, io.TeeReader(resp.Body, hasher)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
 
 // extractTagFromTar extracts just the os-release file from the tarball